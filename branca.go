@@ -3,15 +3,10 @@ package branca
 
 import (
 	"bytes"
-	"crypto/rand"
-	"encoding/binary"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"time"
-
-	"github.com/eknkc/basex"
-	"golang.org/x/crypto/chacha20poly1305"
 )
 
 const (
@@ -39,14 +34,24 @@ func (e *ErrExpiredToken) Error() string {
 	return fmt.Sprintf("token is expired by %v", delta)
 }
 
-// Branca holds a key of exactly 32 bytes. The nonce and timestamp are used for acceptance tests.
+// Branca holds a key of exactly 32 bytes. The nonce and timestamp are used
+// for acceptance tests. It is a thin, convenience wrapper around Codec:
+// every Encode/Decode builds (or rebuilds, for a KeyRing) the Codec it
+// needs from the current key material rather than caching one, since
+// Key and the key ring can be swapped out at any time. Code that encodes
+// or decodes many tokens against a fixed key should use Codec directly.
 type Branca struct {
-	Key       string
-	nonce     string
-	ttl       uint32
-	timestamp uint32
+	Key         string
+	nonce       string
+	ttl         uint32
+	timestamp   uint32
+	keyring     *KeyRing
+	compression *compressionConfig
 }
 
+// BrancaOption configures a Branca at construction time.
+type BrancaOption func(*Branca)
+
 // SetTTL sets a Time To Live on the token for valid tokens.
 func (b *Branca) SetTTL(ttl uint32) {
 	b.ttl = ttl
@@ -63,55 +68,76 @@ func (b *Branca) setNonce(nonce string) {
 }
 
 // NewBranca creates a *Branca struct.
-func NewBranca(key string) (b *Branca) {
-	return &Branca{
-		Key: key,
+func NewBranca(key string, opts ...BrancaOption) (b *Branca) {
+	b = &Branca{Key: key}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// NewBrancaWithKeyRing creates a *Branca backed by a KeyRing instead of a
+// single key. EncodeBinary always encrypts with the ring's primary key;
+// DecodeToBinary tries every key in the ring, in order, until one decrypts
+// the token successfully.
+func NewBrancaWithKeyRing(kr *KeyRing, opts ...BrancaOption) *Branca {
+	b := &Branca{keyring: kr}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// encodeKey returns the raw key bytes EncodeBinary should seal with: the
+// key ring's primary key if one is configured, or b.Key otherwise.
+func (b *Branca) encodeKey() ([]byte, error) {
+	if b.keyring != nil {
+		primary, err := b.keyring.Primary()
+		if err != nil {
+			return nil, err
+		}
+		return bytes.NewBufferString(primary.Key).Bytes(), nil
 	}
+	return bytes.NewBufferString(b.Key).Bytes(), nil
 }
 
 // EncodeBinary encodes the data matching the format:
 // Version (byte) || Timestamp ([4]byte) || Nonce ([24]byte) || Ciphertext ([]byte) || Tag ([16]byte)
 func (b *Branca) EncodeBinary(data []byte) (string, error) {
-	var timestamp uint32
-	var nonce []byte
-	if b.timestamp == 0 {
-		b.timestamp = uint32(time.Now().Unix())
+	key, err := b.encodeKey()
+	if err != nil {
+		return "", err
+	}
+	codec, err := NewCodec(key)
+	if err != nil {
+		return "", err
 	}
-	timestamp = b.timestamp
 
-	if len(b.nonce) == 0 {
-		nonce = make([]byte, 24)
-		if _, err := rand.Read(nonce); err != nil {
+	framed := data
+	if b.compression != nil {
+		framed, err = b.framePayload(data)
+		if err != nil {
 			return "", err
 		}
-	} else {
-		noncebytes, err := hex.DecodeString(b.nonce)
+	}
+
+	var opts []EncodeOption
+	if b.timestamp != 0 {
+		opts = append(opts, WithTimestamp(b.timestamp))
+	}
+	if len(b.nonce) != 0 {
+		nonce, err := hex.DecodeString(b.nonce)
 		if err != nil {
 			return "", ErrInvalidToken
 		}
-		nonce = noncebytes
-	}
-
-	key := bytes.NewBufferString(b.Key).Bytes()
-
-	timeBuffer := make([]byte, 4)
-	binary.BigEndian.PutUint32(timeBuffer, timestamp)
-	header := append(timeBuffer, nonce...)
-	header = append([]byte{version}, header...)
-
-	xchacha, err := chacha20poly1305.NewX(key)
-	if err != nil {
-		return "", ErrBadKeyLength
+		opts = append(opts, WithNonce(nonce))
 	}
 
-	ciphertext := xchacha.Seal(nil, nonce, data, header)
-
-	token := append(header, ciphertext...)
-	base62, err := basex.NewEncoding(base62)
+	token, err := codec.Encode(nil, framed, opts...)
 	if err != nil {
 		return "", err
 	}
-	return base62.Encode(token), nil
+	return string(token), nil
 }
 
 // EncodeToString encodes the string data.
@@ -121,47 +147,52 @@ func (b *Branca) EncodeToString(data string) (string, error) {
 
 // DecodeToBinary decodes the data.
 func (b *Branca) DecodeToBinary(data string) ([]byte, error) {
-	if len(data) < 62 {
-		return nil, fmt.Errorf("%w: length is less than 62", ErrInvalidToken)
-	}
-	base62, err := basex.NewEncoding(base62)
-	if err != nil {
-		return nil, fmt.Errorf("%v", err)
-	}
-	token, err := base62.Decode(data)
-	if err != nil {
-		return nil, ErrInvalidToken
-	}
-	header := token[:29]
-	ciphertext := token[29:]
-	tokenversion := header[0]
-	timestamp := binary.BigEndian.Uint32(header[1:5])
-	nonce := header[5:]
-
-	if tokenversion != version {
-		return nil, fmt.Errorf("%w: got %#X but expected %#X", ErrInvalidTokenVersion, tokenversion, version)
-	}
-
-	key := bytes.NewBufferString(b.Key).Bytes()
+	payload, _, err := b.DecodeToBinaryWithKey(data)
+	return payload, err
+}
 
-	xchacha, err := chacha20poly1305.NewX(key)
-	if err != nil {
-		return nil, ErrBadKeyLength
-	}
-	payload, err := xchacha.Open(nil, nonce, ciphertext, header)
-	if err != nil {
-		return nil, err
+// DecodeToBinaryWithKey decodes the data the same way DecodeToBinary does,
+// additionally returning the ID of the key ring entry that decrypted the
+// token. When b was created with NewBranca instead of a KeyRing, the
+// returned ID is always empty.
+func (b *Branca) DecodeToBinaryWithKey(data string) ([]byte, string, error) {
+	if b.keyring == nil {
+		codec, err := NewCodec(bytes.NewBufferString(b.Key).Bytes())
+		if err != nil {
+			return nil, "", err
+		}
+		payload, _, err := codec.Decode(nil, []byte(data), WithDecodeTTL(b.ttl))
+		if err != nil {
+			return nil, "", err
+		}
+		if b.compression != nil {
+			payload = unframePayload(payload)
+		}
+		return payload, "", nil
 	}
 
-	if b.ttl != 0 {
-		future := int64(timestamp + b.ttl)
-		now := time.Now().Unix()
-		if future < now {
-			return nil, &ErrExpiredToken{Time: time.Unix(future, 0)}
+	var lastErr error = ErrInvalidToken
+	for _, k := range b.keyring.snapshot() {
+		codec, err := NewCodec(bytes.NewBufferString(k.Key).Bytes())
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		payload, _, err := codec.Decode(nil, []byte(data), WithDecodeTTL(b.ttl))
+		if err != nil {
+			var expired *ErrExpiredToken
+			if errors.As(err, &expired) {
+				return nil, "", err
+			}
+			lastErr = err
+			continue
 		}
+		if b.compression != nil {
+			payload = unframePayload(payload)
+		}
+		return payload, k.ID, nil
 	}
-
-	return payload, nil
+	return nil, "", lastErr
 }
 
 // DecodeToString decodes the data to string.
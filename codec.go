@@ -0,0 +1,206 @@
+package branca
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/eknkc/basex"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// encoding is the package-level base62 table shared by every Codec, built
+// once instead of on every Encode/Decode call.
+var encoding = mustBase62Encoding()
+
+func mustBase62Encoding() *basex.Encoding {
+	enc, err := basex.NewEncoding(base62)
+	if err != nil {
+		panic(err)
+	}
+	return enc
+}
+
+// Option configures a Codec at construction time.
+type Option func(*Codec)
+
+// WithTTL sets the default Time To Live a Codec's Decode enforces when the
+// call site does not override it with a DecodeOption.
+func WithTTL(ttl uint32) Option {
+	return func(c *Codec) {
+		c.ttl = ttl
+	}
+}
+
+// WithClock overrides the clock a Codec uses to evaluate TTL expiry and to
+// stamp tokens when Encode isn't given an explicit timestamp. It defaults
+// to time.Now and exists mainly for tests.
+func WithClock(now func() time.Time) Option {
+	return func(c *Codec) {
+		c.now = now
+	}
+}
+
+// Codec is an immutable, concurrency-safe encoder/decoder for a single
+// 32-byte key. Unlike Branca, a Codec builds its cipher.AEAD once at
+// construction time rather than on every call, and its Encode/Decode
+// methods take per-call overrides as options instead of mutating shared
+// state, so a single Codec can be shared across goroutines.
+type Codec struct {
+	aead cipher.AEAD
+	ttl  uint32
+	now  func() time.Time
+}
+
+// NewCodec builds a Codec for key, which must be exactly 32 bytes.
+func NewCodec(key []byte, opts ...Option) (*Codec, error) {
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, ErrBadKeyLength
+	}
+
+	c := &Codec{aead: aead, now: time.Now}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// encodeParams holds the per-call overrides collected from EncodeOptions.
+type encodeParams struct {
+	nonce     []byte
+	timestamp uint32
+}
+
+// EncodeOption overrides a single Encode call's nonce or timestamp. Tests
+// use these to pin down otherwise-random output; production callers
+// normally pass none.
+type EncodeOption func(*encodeParams)
+
+// WithNonce pins the nonce used by a single Encode call instead of
+// generating one from crypto/rand. nonce must be chacha20poly1305.NonceSizeX
+// bytes long.
+func WithNonce(nonce []byte) EncodeOption {
+	return func(p *encodeParams) {
+		p.nonce = nonce
+	}
+}
+
+// WithTimestamp pins the timestamp embedded by a single Encode call
+// instead of using the codec's clock.
+func WithTimestamp(timestamp uint32) EncodeOption {
+	return func(p *encodeParams) {
+		p.timestamp = timestamp
+	}
+}
+
+// Encode seals payload into a Branca token and appends its base62 text to
+// dst, returning the grown slice. Passing nil for dst allocates a fresh
+// slice; passing a buffer with enough spare capacity lets repeat callers
+// avoid an allocation on the append.
+func (c *Codec) Encode(dst, payload []byte, opts ...EncodeOption) ([]byte, error) {
+	var params encodeParams
+	for _, opt := range opts {
+		opt(&params)
+	}
+
+	nonce := params.nonce
+	if nonce == nil {
+		nonce = make([]byte, chacha20poly1305.NonceSizeX)
+		if _, err := rand.Read(nonce); err != nil {
+			return nil, err
+		}
+	} else if len(nonce) != chacha20poly1305.NonceSizeX {
+		return nil, fmt.Errorf("%w: nonce must be %d bytes", ErrInvalidToken, chacha20poly1305.NonceSizeX)
+	}
+
+	timestamp := params.timestamp
+	if timestamp == 0 {
+		timestamp = uint32(c.now().Unix())
+	}
+
+	header := make([]byte, 5, 29)
+	header[0] = version
+	binary.BigEndian.PutUint32(header[1:5], timestamp)
+	header = append(header, nonce...)
+
+	token := c.aead.Seal(header, nonce, payload, header)
+	return append(dst, encoding.Encode(token)...), nil
+}
+
+// decodeParams holds the per-call overrides collected from DecodeOptions.
+type decodeParams struct {
+	ttl uint32
+	now func() time.Time
+}
+
+// DecodeOption overrides a single Decode call's TTL or clock.
+type DecodeOption func(*decodeParams)
+
+// WithDecodeTTL overrides the Codec's configured TTL for a single Decode
+// call. A value of 0 disables expiry checking for that call.
+func WithDecodeTTL(ttl uint32) DecodeOption {
+	return func(p *decodeParams) {
+		p.ttl = ttl
+	}
+}
+
+// WithDecodeClock overrides the clock used to evaluate TTL expiry for a
+// single Decode call.
+func WithDecodeClock(now func() time.Time) DecodeOption {
+	return func(p *decodeParams) {
+		p.now = now
+	}
+}
+
+// Decode authenticates and decrypts token, appending the plaintext to dst
+// and returning the grown slice along with the timestamp embedded in the
+// token. As with Encode, passing a buffer with spare capacity in dst lets
+// repeat callers avoid an allocation on the append.
+func (c *Codec) Decode(dst, token []byte, opts ...DecodeOption) ([]byte, int64, error) {
+	params := decodeParams{ttl: c.ttl, now: c.now}
+	for _, opt := range opts {
+		opt(&params)
+	}
+	if params.now == nil {
+		params.now = time.Now
+	}
+
+	if len(token) < 62 {
+		return nil, 0, fmt.Errorf("%w: length is less than 62", ErrInvalidToken)
+	}
+	raw, err := encoding.Decode(string(token))
+	if err != nil {
+		return nil, 0, ErrInvalidToken
+	}
+	if len(raw) < 29 {
+		return nil, 0, ErrInvalidToken
+	}
+
+	header := raw[:29]
+	ciphertext := raw[29:]
+	tokenVersion := header[0]
+	timestamp := binary.BigEndian.Uint32(header[1:5])
+	nonce := header[5:29]
+
+	if tokenVersion != version {
+		return nil, 0, fmt.Errorf("%w: got %#X but expected %#X", ErrInvalidTokenVersion, tokenVersion, version)
+	}
+
+	payload, err := c.aead.Open(dst, nonce, ciphertext, header)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if params.ttl != 0 {
+		future := int64(timestamp) + int64(params.ttl)
+		now := params.now().Unix()
+		if future < now {
+			return nil, int64(timestamp), &ErrExpiredToken{Time: time.Unix(future, 0)}
+		}
+	}
+
+	return payload, int64(timestamp), nil
+}
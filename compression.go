@@ -0,0 +1,232 @@
+package branca
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Inner payload header byte, prepended to the plaintext before AEAD
+// sealing so the outer Branca wire format stays spec-compliant while
+// letting participants that opted in compress and decompress payloads.
+const (
+	innerRaw  byte = 0x00
+	innerZstd byte = 0x01
+	innerGzip byte = 0x02
+)
+
+// ErrUnknownCompression is returned by EncodeBinary when a WithCompression
+// Branca is configured with a Compressor that has no inner header tag and
+// a payload meets minSize. Decoding never returns it: a token whose inner
+// header byte is not a recognized tag is returned as raw plaintext.
+var ErrUnknownCompression = errors.New("unknown inner compression algorithm")
+
+// Compressor compresses and decompresses Branca payloads for the optional
+// compressed payload mode enabled via WithCompression.
+type Compressor interface {
+	Compress([]byte) ([]byte, error)
+	Decompress([]byte) ([]byte, error)
+}
+
+// ZstdCompressor compresses payloads with zstd. The zero value uses
+// zstd's default compression level.
+type ZstdCompressor struct {
+	Level zstd.EncoderLevel
+}
+
+// zstdDecoder and zstdEncoders are shared across every ZstdCompressor
+// value of a given level: both EncodeAll and DecodeAll are safe for
+// concurrent use, and a *zstd.Encoder/*zstd.Decoder own background
+// goroutines that are wasteful to spin up and tear down per call.
+var (
+	zstdDecoderOnce sync.Once
+	zstdDecoder     *zstd.Decoder
+	zstdDecoderErr  error
+
+	zstdEncodersMu sync.Mutex
+	zstdEncoders   = map[zstd.EncoderLevel]*zstd.Encoder{}
+)
+
+func getZstdDecoder() (*zstd.Decoder, error) {
+	zstdDecoderOnce.Do(func() {
+		zstdDecoder, zstdDecoderErr = zstd.NewReader(nil)
+	})
+	return zstdDecoder, zstdDecoderErr
+}
+
+func getZstdEncoder(level zstd.EncoderLevel) (*zstd.Encoder, error) {
+	zstdEncodersMu.Lock()
+	defer zstdEncodersMu.Unlock()
+	if enc, ok := zstdEncoders[level]; ok {
+		return enc, nil
+	}
+	enc, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(level))
+	if err != nil {
+		return nil, err
+	}
+	zstdEncoders[level] = enc
+	return enc, nil
+}
+
+// Compress zstd-compresses data.
+func (z ZstdCompressor) Compress(data []byte) ([]byte, error) {
+	level := z.Level
+	if level == 0 {
+		level = zstd.SpeedDefault
+	}
+	enc, err := getZstdEncoder(level)
+	if err != nil {
+		return nil, err
+	}
+	return enc.EncodeAll(data, nil), nil
+}
+
+// Decompress reverses Compress.
+func (z ZstdCompressor) Decompress(data []byte) ([]byte, error) {
+	dec, err := getZstdDecoder()
+	if err != nil {
+		return nil, err
+	}
+	return dec.DecodeAll(data, nil)
+}
+
+// GzipCompressor compresses payloads with gzip. Level follows
+// compress/gzip's own level constants, so its zero value means
+// gzip.NoCompression, not gzip.DefaultCompression.
+type GzipCompressor struct {
+	Level int
+}
+
+// Compress gzip-compresses data.
+func (g GzipCompressor) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, g.Level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decompress reverses Compress.
+func (g GzipCompressor) Decompress(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// compressionConfig is the compression mode a Branca was built with via
+// WithCompression.
+type compressionConfig struct {
+	compressor Compressor
+	minSize    int
+	tag        byte
+	supported  bool
+}
+
+// tagForCompressor returns the inner header byte a built-in Compressor
+// maps to, and whether c is one of the built-ins. The fixed one-byte inner
+// header only has room for algorithms this package ships a decompressor
+// for, so any other Compressor implementation can't be tagged.
+func tagForCompressor(c Compressor) (tag byte, supported bool) {
+	switch c.(type) {
+	case ZstdCompressor, *ZstdCompressor:
+		return innerZstd, true
+	case GzipCompressor, *GzipCompressor:
+		return innerGzip, true
+	default:
+		return innerRaw, false
+	}
+}
+
+// WithCompression enables compressed payload mode on a Branca: EncodeBinary
+// payloads of at least minSize bytes are compressed with c before
+// encryption and framed with a one-byte inner header so DecodeToBinary can
+// tell whether, and how, to decompress. c must be a ZstdCompressor or
+// GzipCompressor (value or pointer); other Compressor implementations have
+// no inner header tag to round-trip through and cause EncodeBinary to fail
+// once a payload meets minSize.
+//
+// Only a Branca built with WithCompression frames its plaintext this way;
+// one without it stays a spec-compliant, unframed Branca token so it keeps
+// interoperating with Codec and with any other Branca implementation. A
+// compression-aware decoder still reads plain, unframed tokens:
+// unframePayload falls back to treating its input as raw plaintext whenever
+// it doesn't look like a recognized inner header. A plain payload whose
+// first byte is 0x00 does look like one, and loses that byte.
+func WithCompression(c Compressor, minSize int) BrancaOption {
+	tag, supported := tagForCompressor(c)
+	cfg := &compressionConfig{compressor: c, minSize: minSize, tag: tag, supported: supported}
+	return func(b *Branca) {
+		b.compression = cfg
+	}
+}
+
+// framePayload prepends the inner compression header to data, compressing
+// it first if data is at least b.compression's minSize. It always writes
+// the header, even when data is left uncompressed, so unframePayload strips
+// a leading tag byte from every payload framed here, even an uncompressed
+// one whose own first byte equals a tag. b.compression is guaranteed non-nil by
+// the only caller, EncodeBinary, which skips framing entirely when
+// compression isn't configured so that Branca stays a spec-compliant,
+// unframed wrapper around Codec by default.
+func (b *Branca) framePayload(data []byte) ([]byte, error) {
+	tag := innerRaw
+	payload := data
+	if len(data) >= b.compression.minSize {
+		if !b.compression.supported {
+			return nil, fmt.Errorf("%w: %T is not a built-in Compressor", ErrUnknownCompression, b.compression.compressor)
+		}
+		compressed, err := b.compression.compressor.Compress(data)
+		if err != nil {
+			return nil, err
+		}
+		tag = b.compression.tag
+		payload = compressed
+	}
+
+	framed := make([]byte, 1+len(payload))
+	framed[0] = tag
+	copy(framed[1:], payload)
+	return framed, nil
+}
+
+// unframePayload reverses framePayload for a compression-aware Branca. Only
+// a WithCompression-configured Branca calls it, so it can still receive an
+// unframed token written by a plain Branca or another Branca implementation
+// that never wrote an inner header: rather than rejecting a leading byte it
+// doesn't recognize, it falls back to returning data unchanged, on the
+// assumption that it's raw plaintext rather than a corrupt frame.
+func unframePayload(data []byte) []byte {
+	if len(data) == 0 {
+		return data
+	}
+
+	tag, body := data[0], data[1:]
+	switch tag {
+	case innerRaw:
+		return body
+	case innerZstd:
+		if out, err := (ZstdCompressor{}).Decompress(body); err == nil {
+			return out
+		}
+	case innerGzip:
+		if out, err := (GzipCompressor{}).Decompress(body); err == nil {
+			return out
+		}
+	}
+	return data
+}
@@ -0,0 +1,138 @@
+package branca
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// TestCompressionShrinksLargeJSON checks that WithCompression meaningfully
+// reduces token size for a large, repetitive JSON payload, and that the
+// compressed token still round-trips.
+func TestCompressionShrinksLargeJSON(t *testing.T) {
+	type record struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+		Bio  string `json:"bio"`
+	}
+	records := make([]record, 40)
+	for i := range records {
+		records[i] = record{ID: i, Name: "user", Bio: strings.Repeat("lorem ipsum dolor sit amet ", 4)}
+	}
+	payload, err := json.Marshal(records)
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+
+	plain := NewBranca(codecTestKey)
+	plainTok, err := plain.EncodeBinary(payload)
+	if err != nil {
+		t.Fatalf("plain encode: %v", err)
+	}
+
+	compressed := NewBranca(codecTestKey, WithCompression(ZstdCompressor{Level: 3}, 512))
+	compressedTok, err := compressed.EncodeBinary(payload)
+	if err != nil {
+		t.Fatalf("compressed encode: %v", err)
+	}
+
+	if len(compressedTok) >= len(plainTok) {
+		t.Fatalf("expected compressed token shorter than plain: got %d bytes vs %d bytes", len(compressedTok), len(plainTok))
+	}
+	if shrinkage := 1 - float64(len(compressedTok))/float64(len(plainTok)); shrinkage < 0.3 {
+		t.Fatalf("expected compression to shrink the token by at least 30%%, got %.1f%%", shrinkage*100)
+	}
+
+	dec, err := compressed.DecodeToBinary(compressedTok)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !bytes.Equal(dec, payload) {
+		t.Fatalf("roundtrip mismatch")
+	}
+}
+
+// TestCompressionAwareDecodeInteroperatesWithPlain checks that a token
+// written by a plain Branca (no WithCompression, so no inner header at all)
+// still decodes cleanly on a WithCompression-configured one: a
+// compression-aware decoder must fall back to treating an unrecognized
+// leading byte as raw plaintext rather than erroring.
+func TestCompressionAwareDecodeInteroperatesWithPlain(t *testing.T) {
+	payload := []byte("hello world, this message is long enough to exceed any small threshold")
+
+	plain := NewBranca(codecTestKey)
+	aware := NewBranca(codecTestKey, WithCompression(GzipCompressor{}, 8))
+
+	plainTok, err := plain.EncodeBinary(payload)
+	if err != nil {
+		t.Fatalf("plain encode: %v", err)
+	}
+	got, err := aware.DecodeToBinary(plainTok)
+	if err != nil {
+		t.Fatalf("compression-aware decode of a plain-encoded token: %v", err)
+	}
+	if !bytes.Equal(got, payload) {
+		t.Fatalf("got %q, want %q", got, payload)
+	}
+}
+
+// TestCompressionAwareRoundTripsBelowMinSize checks that a payload shorter
+// than WithCompression's minSize, which framePayload leaves uncompressed,
+// still round-trips exactly even when its first byte collides with one of
+// the inner header tags. Regression test for a bug where unframePayload
+// stripped that leading byte as if it were always a tag, but framePayload
+// only wrote one for payloads that met minSize.
+func TestCompressionAwareRoundTripsBelowMinSize(t *testing.T) {
+	payload := []byte{0x00, 'h', 'i'}
+	aware := NewBranca(codecTestKey, WithCompression(GzipCompressor{}, 50))
+
+	tok, err := aware.EncodeBinary(payload)
+	if err != nil {
+		t.Fatalf("EncodeBinary: %v", err)
+	}
+	got, err := aware.DecodeToBinary(tok)
+	if err != nil {
+		t.Fatalf("DecodeToBinary: %v", err)
+	}
+	if !bytes.Equal(got, payload) {
+		t.Fatalf("got %q, want %q", got, payload)
+	}
+}
+
+// TestPlainEncodeMatchesCodec checks that a plain Branca (no
+// WithCompression) stays a spec-compliant, unframed wrapper around Codec:
+// a token from either one decodes cleanly on the other.
+func TestPlainEncodeMatchesCodec(t *testing.T) {
+	payload := []byte("the quick brown fox jumps over the lazy dog")
+
+	codec, err := NewCodec([]byte(codecTestKey))
+	if err != nil {
+		t.Fatalf("NewCodec: %v", err)
+	}
+	plain := NewBranca(codecTestKey)
+
+	token, err := codec.Encode(nil, payload)
+	if err != nil {
+		t.Fatalf("Codec.Encode: %v", err)
+	}
+	got, err := plain.DecodeToBinary(string(token))
+	if err != nil {
+		t.Fatalf("Branca.DecodeToBinary of a Codec-encoded token: %v", err)
+	}
+	if !bytes.Equal(got, payload) {
+		t.Fatalf("got %q, want %q", got, payload)
+	}
+
+	brancaTok, err := plain.EncodeBinary(payload)
+	if err != nil {
+		t.Fatalf("Branca.EncodeBinary: %v", err)
+	}
+	got, _, err = codec.Decode(nil, []byte(brancaTok))
+	if err != nil {
+		t.Fatalf("Codec.Decode of a Branca-encoded token: %v", err)
+	}
+	if !bytes.Equal(got, payload) {
+		t.Fatalf("got %q, want %q", got, payload)
+	}
+}
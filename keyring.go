@@ -0,0 +1,93 @@
+package branca
+
+import (
+	"errors"
+	"sync"
+)
+
+var (
+	// ErrKeyNotFound indicates a KeyRing operation referenced an unknown key ID.
+	ErrKeyNotFound = errors.New("key not found in key ring")
+	// ErrEmptyKeyRing indicates an operation was attempted on a KeyRing with no keys.
+	ErrEmptyKeyRing = errors.New("key ring is empty")
+)
+
+// Key is a single entry in a KeyRing: a 32-byte raw key with an optional
+// ID used to report which key decoded a token.
+type Key struct {
+	ID  string
+	Key string
+}
+
+// KeyRing holds an ordered list of keys, the first of which is the primary
+// key used for encoding. It allows operators to roll keys without
+// invalidating tokens encoded under an older key: DecodeToBinary tries
+// every key in order until one succeeds. A KeyRing is safe for concurrent
+// use.
+type KeyRing struct {
+	mu   sync.RWMutex
+	keys []Key
+}
+
+// NewKeyRing creates a KeyRing with keys as its initial, ordered contents.
+// The first key is the primary key.
+func NewKeyRing(keys ...Key) *KeyRing {
+	kr := &KeyRing{}
+	kr.keys = append(kr.keys, keys...)
+	return kr
+}
+
+// Add appends key to the end of the ring.
+func (kr *KeyRing) Add(key Key) {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	kr.keys = append(kr.keys, key)
+}
+
+// Rotate promotes the key with the given ID to primary. It returns
+// ErrKeyNotFound if no key with that ID is in the ring.
+func (kr *KeyRing) Rotate(id string) error {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	for i, k := range kr.keys {
+		if k.ID == id {
+			kr.keys[0], kr.keys[i] = kr.keys[i], kr.keys[0]
+			return nil
+		}
+	}
+	return ErrKeyNotFound
+}
+
+// Remove deletes the key with the given ID from the ring. It returns
+// ErrKeyNotFound if no key with that ID is in the ring.
+func (kr *KeyRing) Remove(id string) error {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	for i, k := range kr.keys {
+		if k.ID == id {
+			kr.keys = append(kr.keys[:i], kr.keys[i+1:]...)
+			return nil
+		}
+	}
+	return ErrKeyNotFound
+}
+
+// Primary returns the ring's current primary (first) key.
+func (kr *KeyRing) Primary() (Key, error) {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	if len(kr.keys) == 0 {
+		return Key{}, ErrEmptyKeyRing
+	}
+	return kr.keys[0], nil
+}
+
+// snapshot returns a copy of the ring's keys in order, safe to range over
+// without holding the ring's lock.
+func (kr *KeyRing) snapshot() []Key {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	out := make([]Key, len(kr.keys))
+	copy(out, kr.keys)
+	return out
+}
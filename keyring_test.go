@@ -0,0 +1,145 @@
+package branca
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+const (
+	keyringTestKeyA = "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	keyringTestKeyB = "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+)
+
+func TestKeyRingAddRotateRemove(t *testing.T) {
+	kr := NewKeyRing(Key{ID: "a", Key: keyringTestKeyA})
+
+	primary, err := kr.Primary()
+	if err != nil {
+		t.Fatalf("Primary: %v", err)
+	}
+	if primary.ID != "a" {
+		t.Fatalf("got primary %q, want %q", primary.ID, "a")
+	}
+
+	kr.Add(Key{ID: "b", Key: keyringTestKeyB})
+	if primary, err = kr.Primary(); err != nil || primary.ID != "a" {
+		t.Fatalf("adding a key should not change the primary, got %q, err %v", primary.ID, err)
+	}
+
+	if err := kr.Rotate("b"); err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	if primary, err = kr.Primary(); err != nil || primary.ID != "b" {
+		t.Fatalf("got primary %q after Rotate, want %q", primary.ID, "b")
+	}
+
+	if err := kr.Rotate("missing"); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("Rotate(missing): got %v, want ErrKeyNotFound", err)
+	}
+
+	if err := kr.Remove("a"); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if err := kr.Remove("a"); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("Remove(already removed): got %v, want ErrKeyNotFound", err)
+	}
+
+	if err := kr.Remove("b"); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if _, err := kr.Primary(); !errors.Is(err, ErrEmptyKeyRing) {
+		t.Fatalf("Primary on empty ring: got %v, want ErrEmptyKeyRing", err)
+	}
+}
+
+// TestBrancaKeyRingDecodeReportsDecodingKeyID checks that DecodeToBinaryWithKey
+// identifies which ring entry actually decrypted the token, including after
+// a rotation changes which key is primary (and therefore which key newly
+// encoded tokens use).
+func TestBrancaKeyRingDecodeReportsDecodingKeyID(t *testing.T) {
+	kr := NewKeyRing(Key{ID: "a", Key: keyringTestKeyA}, Key{ID: "b", Key: keyringTestKeyB})
+	b := NewBrancaWithKeyRing(kr)
+
+	payload := []byte("encoded under key a")
+	tok, err := b.EncodeBinary(payload)
+	if err != nil {
+		t.Fatalf("EncodeBinary: %v", err)
+	}
+	got, id, err := b.DecodeToBinaryWithKey(tok)
+	if err != nil {
+		t.Fatalf("DecodeToBinaryWithKey: %v", err)
+	}
+	if id != "a" {
+		t.Fatalf("got key id %q, want %q", id, "a")
+	}
+	if !bytes.Equal(got, payload) {
+		t.Fatalf("got %q, want %q", got, payload)
+	}
+
+	if err := kr.Rotate("b"); err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+
+	got, id, err = b.DecodeToBinaryWithKey(tok)
+	if err != nil {
+		t.Fatalf("DecodeToBinaryWithKey after rotation: %v", err)
+	}
+	if id != "a" {
+		t.Fatalf("got key id %q after rotation, want %q", id, "a")
+	}
+	if !bytes.Equal(got, payload) {
+		t.Fatalf("got %q, want %q", got, payload)
+	}
+}
+
+// TestBrancaKeyRingDecodeReturnsExpiredNotAuthFailure guards against a bug
+// where a key ring decode that successfully opened a token, but found it
+// expired, kept trying the remaining keys in the ring instead of returning
+// the expiry immediately. The next (wrong) key's generic auth failure then
+// clobbered the *ErrExpiredToken*, so callers using errors.As to detect
+// expiry never saw it.
+func TestBrancaKeyRingDecodeReturnsExpiredNotAuthFailure(t *testing.T) {
+	kr := NewKeyRing(Key{ID: "a", Key: keyringTestKeyA}, Key{ID: "b", Key: keyringTestKeyB})
+	b := NewBrancaWithKeyRing(kr)
+	b.SetTTL(1)
+	b.setTimeStamp(1)
+
+	tok, err := b.EncodeBinary([]byte("stale"))
+	if err != nil {
+		t.Fatalf("EncodeBinary: %v", err)
+	}
+
+	_, _, err = b.DecodeToBinaryWithKey(tok)
+	var expired *ErrExpiredToken
+	if !errors.As(err, &expired) {
+		t.Fatalf("got %v, want *ErrExpiredToken", err)
+	}
+}
+
+// TestKeyRingConcurrentAccess mirrors TestCodecConcurrentEncodeDecode: many
+// goroutines Add/Rotate/Remove/Primary/snapshot a shared KeyRing at once,
+// exercising its sync.RWMutex under `go test -race`.
+func TestKeyRingConcurrentAccess(t *testing.T) {
+	kr := NewKeyRing(Key{ID: "seed", Key: keyringTestKeyA})
+
+	const goroutines = 50
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			keyID := fmt.Sprintf("key-%d", id)
+			kr.Add(Key{ID: keyID, Key: keyringTestKeyB})
+			_ = kr.Rotate(keyID)
+			if _, err := kr.Primary(); err != nil {
+				t.Errorf("goroutine %d: Primary: %v", id, err)
+			}
+			_ = kr.snapshot()
+			_ = kr.Remove(keyID)
+		}(g)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,182 @@
+package branca
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Default Argon2id parameters used when a KDFOptions is not fully populated.
+const (
+	DefaultKDFTime        uint32 = 1
+	DefaultKDFMemory      uint32 = 64 * 1024
+	DefaultKDFParallelism uint8  = 4
+	DefaultKDFSaltLen     uint32 = 16
+	DefaultKDFKeyLen      uint32 = 32
+)
+
+// ErrInvalidEnvelope indicates a PasswordEnvelope could not be parsed.
+var ErrInvalidEnvelope = errors.New("invalid password envelope")
+
+// KDFOptions configures the Argon2id derivation used to turn a passphrase
+// into a Branca key. Salt is generated by DeriveKey when left empty, and the
+// generated value is written back so callers can persist it alongside the
+// token (see PasswordEnvelope).
+type KDFOptions struct {
+	// Time is the number of Argon2id iterations.
+	Time uint32
+	// Memory is the amount of memory to use, in KiB.
+	Memory uint32
+	// Parallelism is the number of lanes/threads.
+	Parallelism uint8
+	// SaltLen is the length of a generated salt, in bytes. Ignored if Salt is set.
+	SaltLen uint32
+	// KeyLen is the length of the derived key, in bytes. Branca requires 32.
+	KeyLen uint32
+	// Salt is the Argon2id salt. Generated automatically when empty.
+	Salt []byte
+}
+
+// DefaultKDFOptions returns the recommended Argon2id parameters for
+// deriving a Branca key from a password.
+func DefaultKDFOptions() *KDFOptions {
+	return &KDFOptions{
+		Time:        DefaultKDFTime,
+		Memory:      DefaultKDFMemory,
+		Parallelism: DefaultKDFParallelism,
+		SaltLen:     DefaultKDFSaltLen,
+		KeyLen:      DefaultKDFKeyLen,
+	}
+}
+
+// fillDefaults populates zero-valued fields with the recommended defaults
+// and generates a random salt of SaltLen bytes if none was supplied.
+func (o *KDFOptions) fillDefaults() error {
+	if o.Time == 0 {
+		o.Time = DefaultKDFTime
+	}
+	if o.Memory == 0 {
+		o.Memory = DefaultKDFMemory
+	}
+	if o.Parallelism == 0 {
+		o.Parallelism = DefaultKDFParallelism
+	}
+	if o.SaltLen == 0 {
+		o.SaltLen = DefaultKDFSaltLen
+	}
+	if o.KeyLen == 0 {
+		o.KeyLen = DefaultKDFKeyLen
+	}
+	if len(o.Salt) == 0 {
+		salt := make([]byte, o.SaltLen)
+		if _, err := rand.Read(salt); err != nil {
+			return err
+		}
+		o.Salt = salt
+	}
+	return nil
+}
+
+// DeriveKey derives a Branca key from password using Argon2id. opts may be
+// nil to use DefaultKDFOptions. If opts.Salt is empty, a random salt is
+// generated and written back into opts so the caller can persist it.
+func DeriveKey(password string, opts *KDFOptions) ([]byte, error) {
+	if opts == nil {
+		opts = DefaultKDFOptions()
+	}
+	if err := opts.fillDefaults(); err != nil {
+		return nil, err
+	}
+	return argon2.IDKey([]byte(password), opts.Salt, opts.Time, opts.Memory, opts.Parallelism, opts.KeyLen), nil
+}
+
+// NewBrancaFromPassword derives a 32-byte key from password via Argon2id
+// (see DeriveKey) and returns a *Branca using that key. opts may be nil to
+// use DefaultKDFOptions; its Salt field is populated with the salt that was
+// used so it can be packed into a PasswordEnvelope.
+func NewBrancaFromPassword(password string, opts *KDFOptions) (*Branca, error) {
+	key, err := DeriveKey(password, opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != 32 {
+		return nil, ErrBadKeyLength
+	}
+	return NewBranca(string(key)), nil
+}
+
+// PasswordEnvelope packs a KDFOptions and a Branca token together so that a
+// recipient holding only the password can re-derive the key without any
+// out-of-band configuration. The wire format is a base64 URL-safe, unpadded
+// header (time, memory, parallelism, salt) followed by a "." separator and
+// the Branca token.
+type PasswordEnvelope struct {
+	Opts  *KDFOptions
+	Token string
+}
+
+// EncodePasswordEnvelope packs opts and token into the envelope string
+// described on PasswordEnvelope.
+func EncodePasswordEnvelope(opts *KDFOptions, token string) (string, error) {
+	if opts == nil || len(opts.Salt) == 0 {
+		return "", fmt.Errorf("%w: missing salt", ErrInvalidEnvelope)
+	}
+	if len(opts.Salt) > 255 {
+		return "", fmt.Errorf("%w: salt too long", ErrInvalidEnvelope)
+	}
+
+	header := make([]byte, 0, 9+len(opts.Salt))
+	buf4 := make([]byte, 4)
+
+	binary.BigEndian.PutUint32(buf4, opts.Time)
+	header = append(header, buf4...)
+
+	binary.BigEndian.PutUint32(buf4, opts.Memory)
+	header = append(header, buf4...)
+
+	header = append(header, opts.Parallelism)
+	header = append(header, byte(len(opts.Salt)))
+	header = append(header, opts.Salt...)
+
+	encodedHeader := base64.RawURLEncoding.EncodeToString(header)
+	return encodedHeader + "." + token, nil
+}
+
+// DecodePasswordEnvelope splits an envelope produced by
+// EncodePasswordEnvelope back into the KDFOptions it was packed with and
+// the underlying Branca token. KeyLen is not part of the wire format and is
+// left at DefaultKDFKeyLen.
+func DecodePasswordEnvelope(envelope string) (*KDFOptions, string, error) {
+	parts := strings.SplitN(envelope, ".", 2)
+	if len(parts) != 2 {
+		return nil, "", fmt.Errorf("%w: missing separator", ErrInvalidEnvelope)
+	}
+
+	header, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrInvalidEnvelope, err)
+	}
+	if len(header) < 10 {
+		return nil, "", fmt.Errorf("%w: header too short", ErrInvalidEnvelope)
+	}
+
+	opts := &KDFOptions{
+		Time:        binary.BigEndian.Uint32(header[0:4]),
+		Memory:      binary.BigEndian.Uint32(header[4:8]),
+		Parallelism: header[8],
+		KeyLen:      DefaultKDFKeyLen,
+	}
+	saltLen := int(header[9])
+	if len(header) != 10+saltLen {
+		return nil, "", fmt.Errorf("%w: bad salt length", ErrInvalidEnvelope)
+	}
+	opts.Salt = header[10:]
+	opts.SaltLen = uint32(saltLen)
+
+	return opts, parts[1], nil
+}
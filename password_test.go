@@ -0,0 +1,124 @@
+package branca
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// fastKDFOptions returns KDFOptions cheap enough to run Argon2id many times
+// in a test without slowing the suite down; DefaultKDFOptions' memory cost
+// is intentionally much higher for real use.
+func fastKDFOptions() *KDFOptions {
+	return &KDFOptions{
+		Time:        1,
+		Memory:      8 * 1024,
+		Parallelism: 1,
+		SaltLen:     16,
+		KeyLen:      32,
+	}
+}
+
+func TestDeriveKeyIsDeterministicForFixedSalt(t *testing.T) {
+	opts := fastKDFOptions()
+	key1, err := DeriveKey("hunter2", opts)
+	if err != nil {
+		t.Fatalf("DeriveKey: %v", err)
+	}
+	if len(opts.Salt) == 0 {
+		t.Fatalf("DeriveKey did not write back a generated salt")
+	}
+
+	key2, err := DeriveKey("hunter2", opts)
+	if err != nil {
+		t.Fatalf("DeriveKey (second call): %v", err)
+	}
+	if !bytes.Equal(key1, key2) {
+		t.Fatalf("same password and salt produced different keys")
+	}
+
+	otherOpts := fastKDFOptions()
+	key3, err := DeriveKey("hunter2", otherOpts)
+	if err != nil {
+		t.Fatalf("DeriveKey (fresh salt): %v", err)
+	}
+	if bytes.Equal(key1, key3) {
+		t.Fatalf("different salts produced the same key")
+	}
+}
+
+// TestNewBrancaFromPasswordRoundTrip checks that a Branca derived from a
+// password, packed into a PasswordEnvelope, and later re-derived from the
+// same password and the envelope's packed salt, decodes the original token.
+func TestNewBrancaFromPasswordRoundTrip(t *testing.T) {
+	payload := []byte("shared secret over a password-derived key")
+
+	opts := fastKDFOptions()
+	b, err := NewBrancaFromPassword("correct horse battery staple", opts)
+	if err != nil {
+		t.Fatalf("NewBrancaFromPassword: %v", err)
+	}
+	tok, err := b.EncodeBinary(payload)
+	if err != nil {
+		t.Fatalf("EncodeBinary: %v", err)
+	}
+
+	envelope, err := EncodePasswordEnvelope(opts, tok)
+	if err != nil {
+		t.Fatalf("EncodePasswordEnvelope: %v", err)
+	}
+
+	decodedOpts, decodedTok, err := DecodePasswordEnvelope(envelope)
+	if err != nil {
+		t.Fatalf("DecodePasswordEnvelope: %v", err)
+	}
+	if decodedTok != tok {
+		t.Fatalf("got token %q, want %q", decodedTok, tok)
+	}
+
+	recipient, err := NewBrancaFromPassword("correct horse battery staple", decodedOpts)
+	if err != nil {
+		t.Fatalf("NewBrancaFromPassword (recipient): %v", err)
+	}
+	got, err := recipient.DecodeToBinary(decodedTok)
+	if err != nil {
+		t.Fatalf("DecodeToBinary: %v", err)
+	}
+	if !bytes.Equal(got, payload) {
+		t.Fatalf("got %q, want %q", got, payload)
+	}
+}
+
+func TestEncodePasswordEnvelopeErrors(t *testing.T) {
+	if _, err := EncodePasswordEnvelope(nil, "token"); !errors.Is(err, ErrInvalidEnvelope) {
+		t.Fatalf("nil opts: got %v, want ErrInvalidEnvelope", err)
+	}
+	if _, err := EncodePasswordEnvelope(&KDFOptions{}, "token"); !errors.Is(err, ErrInvalidEnvelope) {
+		t.Fatalf("missing salt: got %v, want ErrInvalidEnvelope", err)
+	}
+
+	oversized := &KDFOptions{Salt: make([]byte, 256)}
+	if _, err := EncodePasswordEnvelope(oversized, "token"); !errors.Is(err, ErrInvalidEnvelope) {
+		t.Fatalf("oversized salt: got %v, want ErrInvalidEnvelope", err)
+	}
+}
+
+func TestDecodePasswordEnvelopeErrors(t *testing.T) {
+	if _, _, err := DecodePasswordEnvelope("no-separator-here"); !errors.Is(err, ErrInvalidEnvelope) {
+		t.Fatalf("missing separator: got %v, want ErrInvalidEnvelope", err)
+	}
+
+	opts := &KDFOptions{Time: 1, Memory: 8 * 1024, Parallelism: 1, Salt: []byte("0123456789abcdef")}
+	envelope, err := EncodePasswordEnvelope(opts, "sometoken")
+	if err != nil {
+		t.Fatalf("EncodePasswordEnvelope: %v", err)
+	}
+
+	// Truncate the header so its declared salt length no longer matches
+	// what's actually present.
+	sep := bytes.IndexByte([]byte(envelope), '.')
+	truncated := envelope[:sep-4] + envelope[sep:]
+	if _, _, err := DecodePasswordEnvelope(truncated); !errors.Is(err, ErrInvalidEnvelope) {
+		t.Fatalf("bad salt length: got %v, want ErrInvalidEnvelope", err)
+	}
+}
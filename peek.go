@@ -0,0 +1,69 @@
+package branca
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// Header is the metadata embedded in every Branca token, readable without
+// the key or AEAD cost via Peek.
+type Header struct {
+	// Version is the token's magic version byte.
+	Version byte
+	// Timestamp is when the token was encoded.
+	Timestamp time.Time
+	// Nonce is the 24-byte XChaCha20-Poly1305 nonce used to seal the token.
+	Nonce []byte
+	// CiphertextLen is the length in bytes of the ciphertext plus its
+	// 16-byte authentication tag.
+	CiphertextLen int
+}
+
+// Age returns how long ago the token was encoded, relative to now.
+func (h Header) Age() time.Duration {
+	return time.Since(h.Timestamp)
+}
+
+// Peek base62-decodes token and returns its header without invoking the
+// AEAD or requiring a key, so gateways and middleware can inspect, route,
+// or pre-filter tokens before paying the decryption cost.
+func Peek(token string) (Header, error) {
+	if len(token) < 62 {
+		return Header{}, fmt.Errorf("%w: length is less than 62", ErrInvalidToken)
+	}
+	raw, err := encoding.Decode(token)
+	if err != nil {
+		return Header{}, ErrInvalidToken
+	}
+	if len(raw) < 29 {
+		return Header{}, ErrInvalidToken
+	}
+
+	tokenVersion := raw[0]
+	if tokenVersion != version {
+		return Header{}, fmt.Errorf("%w: got %#X but expected %#X", ErrInvalidTokenVersion, tokenVersion, version)
+	}
+
+	nonce := make([]byte, 24)
+	copy(nonce, raw[5:29])
+
+	return Header{
+		Version:       tokenVersion,
+		Timestamp:     time.Unix(int64(binary.BigEndian.Uint32(raw[1:5])), 0),
+		Nonce:         nonce,
+		CiphertextLen: len(raw) - 29,
+	}, nil
+}
+
+// IsExpired reports whether token's embedded timestamp is older than ttl
+// seconds, without invoking the AEAD or requiring a key. It is a cheap
+// gateway-level check; the authoritative expiry check still happens in
+// Codec.Decode/Branca.DecodeToBinary once the token is actually opened.
+func IsExpired(token string, ttl uint32) (bool, error) {
+	h, err := Peek(token)
+	if err != nil {
+		return false, err
+	}
+	return h.Age() > time.Duration(ttl)*time.Second, nil
+}
@@ -0,0 +1,102 @@
+package branca
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPeek(t *testing.T) {
+	b := NewBranca(codecTestKey)
+	tok, err := b.EncodeBinary([]byte("payload"))
+	if err != nil {
+		t.Fatalf("EncodeBinary: %v", err)
+	}
+
+	h, err := Peek(tok)
+	if err != nil {
+		t.Fatalf("Peek: %v", err)
+	}
+	if h.Version != version {
+		t.Fatalf("got version %#X, want %#X", h.Version, version)
+	}
+	if len(h.Nonce) != 24 {
+		t.Fatalf("got nonce length %d, want 24", len(h.Nonce))
+	}
+	if time.Since(h.Timestamp) > 5*time.Second {
+		t.Fatalf("got timestamp %v, want close to now", h.Timestamp)
+	}
+	if h.Age() > 5*time.Second {
+		t.Fatalf("got age %v, want close to 0", h.Age())
+	}
+	if h.CiphertextLen != len("payload")+16 {
+		t.Fatalf("got ciphertext length %d, want %d", h.CiphertextLen, len("payload")+16)
+	}
+}
+
+func TestPeekErrors(t *testing.T) {
+	if _, err := Peek("tooshort"); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("short token: got %v, want ErrInvalidToken", err)
+	}
+
+	b := NewBranca(codecTestKey)
+	tok, err := b.EncodeBinary([]byte("payload"))
+	if err != nil {
+		t.Fatalf("EncodeBinary: %v", err)
+	}
+	corrupted := "A" + tok[1:]
+	if _, err := Peek(corrupted); !errors.Is(err, ErrInvalidTokenVersion) {
+		t.Fatalf("bad version byte: got %v, want ErrInvalidTokenVersion", err)
+	}
+}
+
+func TestIsExpired(t *testing.T) {
+	b := NewBranca(codecTestKey)
+	b.setTimeStamp(1)
+	tok, err := b.EncodeBinary([]byte("payload"))
+	if err != nil {
+		t.Fatalf("EncodeBinary: %v", err)
+	}
+
+	expired, err := IsExpired(tok, 1)
+	if err != nil {
+		t.Fatalf("IsExpired: %v", err)
+	}
+	if !expired {
+		t.Fatalf("got expired=false for a token timestamped at the epoch, want true")
+	}
+
+	fresh := NewBranca(codecTestKey)
+	freshTok, err := fresh.EncodeBinary([]byte("payload"))
+	if err != nil {
+		t.Fatalf("EncodeBinary: %v", err)
+	}
+	expired, err = IsExpired(freshTok, 3600)
+	if err != nil {
+		t.Fatalf("IsExpired: %v", err)
+	}
+	if expired {
+		t.Fatalf("got expired=true for a fresh token with a 1h ttl, want false")
+	}
+}
+
+func TestPeekThenDecodeAgree(t *testing.T) {
+	payload := []byte("peek before you pay the AEAD cost")
+	b := NewBranca(codecTestKey)
+	tok, err := b.EncodeBinary(payload)
+	if err != nil {
+		t.Fatalf("EncodeBinary: %v", err)
+	}
+
+	if _, err := Peek(tok); err != nil {
+		t.Fatalf("Peek: %v", err)
+	}
+	got, err := b.DecodeToBinary(tok)
+	if err != nil {
+		t.Fatalf("DecodeToBinary: %v", err)
+	}
+	if !bytes.Equal(got, payload) {
+		t.Fatalf("got %q, want %q", got, payload)
+	}
+}